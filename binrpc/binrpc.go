@@ -5,7 +5,6 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
-	"log"
 	"math/rand"
 )
 
@@ -79,44 +78,77 @@ func (s BinRpcString) Encode(w io.Writer) error {
 	return WritePacket(w, BinRpcTypeString, val)
 }
 
+// payloadLengthSize returns the minimum number of big-endian bytes
+// needed to represent n in the header's 2-bit LL field (which can
+// encode sizes 1-4). Zero still requires 1 byte, since LL itself is
+// encoded as size-1 and has no way to express a zero-byte field. It
+// returns 0 if n cannot be represented in 4 bytes.
+func payloadLengthSize(n uint64) uint8 {
+	switch {
+	case n <= 0xFF:
+		return 1
+	case n <= 0xFFFF:
+		return 2
+	case n <= 0xFFFFFF:
+		return 3
+	case n <= 0xFFFFFFFF:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// writeUintBytes writes the low n big-endian bytes of v to w.
+func writeUintBytes(w *bytes.Buffer, v uint64, n uint8) error {
+	buf := make([]byte, n)
+	for i := int(n) - 1; i >= 0; i-- {
+		buf[i] = byte(v)
+		v >>= 8
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
 // ConstructHeader takes the payload length and cookie
 // and returns a byte array header
 func ConstructHeader(header *bytes.Buffer, payloadLength uint64, cookie uint32) error {
+	return ConstructHeaderFlags(header, payloadLength, cookie, FlagNone)
+}
+
+// ConstructHeaderFlags is like ConstructHeader but additionally sets
+// the header's Flags nibble, e.g. to mark the payload as compressed.
+func ConstructHeaderFlags(header *bytes.Buffer, payloadLength uint64, cookie uint32, flags uint) error {
 	// Add the Magic/Version
 	err := header.WriteByte(byte(BinRpcMagicVersion))
 	if err != nil {
-		return fmt.Errorf("Failed to write magic/version to header: %s", err.Error())
+		return fmt.Errorf("failed to write magic/version to header: %w", err)
 	}
 
 	// Find the size (in bytes) of the payload length
-	plSize := uint8(payloadLength / 256)
-	if payloadLength%256 > 0 {
-		plSize += 1
+	plSize := payloadLengthSize(payloadLength)
+	if plSize == 0 {
+		return fmt.Errorf("payload length %d is too large to encode", payloadLength)
 	}
 
-	//log.Printf("Payload length is %d, and the length of that value in bytes is %d", payloadLength, plSize)
-
 	// Find the size of the cookie
-	cookieSize := binary.Size(cookie)
-	if cookieSize < 0 {
+	cookieSize := uint8(binary.Size(cookie))
+	if cookieSize == 0 {
 		return fmt.Errorf("failed to determine byte length of cookie")
 	}
 
-	// Write the Flags/LL/CL byte (flags hard-coded to 0x0 for now)
-	err = header.WriteByte(byte(0x0<<4 | uint(plSize-1)<<2 | uint(cookieSize-1)))
+	// Write the Flags/LL/CL byte
+	err = header.WriteByte(byte(flags<<4 | uint(plSize-1)<<2 | uint(cookieSize-1)))
 	if err != nil {
 		return fmt.Errorf("failed to write flags byte: %w", err)
 	}
 
-	// Write the payload length
-	err = binary.Write(header, binary.BigEndian, uint8(payloadLength))
-	if err != nil {
+	// Write exactly plSize bytes of the payload length
+	if err := writeUintBytes(header, payloadLength, plSize); err != nil {
 		return fmt.Errorf("failed to append payload length: %w", err)
 	}
 
-	// Write the cookie
-	err = binary.Write(header, binary.BigEndian, cookie)
-	if err != nil {
+	// Write exactly cookieSize bytes of the cookie
+	if err := writeUintBytes(header, uint64(cookie), cookieSize); err != nil {
 		return fmt.Errorf("failed to append cookie: %w", err)
 	}
 
@@ -127,25 +159,25 @@ func ConstructHeader(header *bytes.Buffer, payloadLength uint64, cookie uint32)
 // into a BinRpc payload
 func ConstructPayload(payload *bytes.Buffer, valType uint, val interface{}) error {
 	// Calculate the minimum byte-size of the value
-	valueLength := int8(binary.Size(val))
-	if valueLength < 0 {
+	rawLength := binary.Size(val)
+	if rawLength < 0 {
 		return fmt.Errorf("failed to determine byte-size of value")
 	}
+	valueLength := uint64(rawLength)
 
 	// If the minimum byte-size is larger than will
 	// fit in three bits, set the size flag = 1
 	var sflag uint
 	var size uint
-	if valueLength > 8 { // 2^3 = 8
+	if valueLength > 7 { // size is a 3-bit field, max direct value 7
 		sflag = 1
 		// If sflag = 1, size now describes the byte size
 		// of the _length_ of the value instead of the value itself
-		if temp_size := binary.Size(valueLength); temp_size < 0 {
-			log.Println("binary size of", valueLength, "is", temp_size)
-			return fmt.Errorf("failed to determine byte-size of value length")
-		} else {
-			size = uint(temp_size)
+		lenSize := payloadLengthSize(valueLength)
+		if lenSize == 0 {
+			return fmt.Errorf("value length %d is too large to encode", valueLength)
 		}
+		size = uint(lenSize)
 	} else {
 		// Otherwise, the size is (directly) the byte-length of the value
 		size = uint(valueLength)
@@ -158,10 +190,9 @@ func ConstructPayload(payload *bytes.Buffer, valType uint, val interface{}) erro
 	}
 
 	// Write the optional value length if our size is too large
-	// to fit in `size`
+	// to fit in `size`, using exactly as many bytes as `size` says
 	if sflag == 1 {
-		err = binary.Write(payload, binary.BigEndian, uint8(valueLength))
-		if err != nil {
+		if err := writeUintBytes(payload, valueLength, uint8(size)); err != nil {
 			return fmt.Errorf("failed to append optional value length: %w", err)
 		}
 	}
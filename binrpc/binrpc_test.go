@@ -2,9 +2,178 @@ package binrpc
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 )
 
+func TestConstructHeaderPayloadLengths(t *testing.T) {
+	cases := []struct {
+		payloadLength uint64
+		wantLLSize    uint8
+	}{
+		{0, 1},
+		{1, 1},
+		{255, 1},
+		{256, 2},
+		{65535, 2},
+		{65536, 3},
+		{1 << 24, 4},
+	}
+
+	for _, c := range cases {
+		header := new(bytes.Buffer)
+		if err := ConstructHeader(header, c.payloadLength, 0x1234); err != nil {
+			t.Fatalf("ConstructHeader(%d): unexpected error: %s", c.payloadLength, err)
+		}
+
+		b := header.Bytes()
+		if len(b) < 2 {
+			t.Fatalf("ConstructHeader(%d): header too short: %x", c.payloadLength, b)
+		}
+
+		llSize := uint8((b[1]>>2)&0x3) + 1
+		if llSize != c.wantLLSize {
+			t.Errorf("ConstructHeader(%d): LL size = %d, want %d", c.payloadLength, llSize, c.wantLLSize)
+		}
+
+		cookieSize := uint8(b[1]&0x3) + 1
+		wantLen := 2 + int(llSize) + int(cookieSize)
+		if len(b) != wantLen {
+			t.Errorf("ConstructHeader(%d): header length = %d, want %d", c.payloadLength, len(b), wantLen)
+		}
+
+		var gotLength uint64
+		for _, by := range b[2 : 2+llSize] {
+			gotLength = gotLength<<8 | uint64(by)
+		}
+		if gotLength != c.payloadLength {
+			t.Errorf("ConstructHeader(%d): encoded length = %d, want %d", c.payloadLength, gotLength, c.payloadLength)
+		}
+	}
+}
+
+func TestConstructPayloadSizeFieldBoundary(t *testing.T) {
+	cases := []struct {
+		name string
+		val  []byte
+	}{
+		{"seven bytes, fits directly in the 3-bit size field", bytes.Repeat([]byte("a"), 7)},
+		{"eight bytes, must take the sflag=1 length-prefixed path", bytes.Repeat([]byte("a"), 8)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			payload := new(bytes.Buffer)
+			if err := ConstructPayload(payload, BinRpcTypeBytes, c.val); err != nil {
+				t.Fatalf("ConstructPayload: unexpected error: %s", err)
+			}
+
+			v, err := decodeValue(bytes.NewReader(payload.Bytes()), "")
+			if err != nil {
+				t.Fatalf("decodeValue: unexpected error: %s", err)
+			}
+
+			got, err := v.AsBytes()
+			if err != nil {
+				t.Fatalf("AsBytes: unexpected error: %s", err)
+			}
+			if !bytes.Equal(got, c.val) {
+				t.Errorf("round-tripped bytes do not match: got %d bytes, want %d", len(got), len(c.val))
+			}
+		})
+	}
+}
+
+func TestConstructPayloadLargeValueRoundTrip(t *testing.T) {
+	large := bytes.Repeat([]byte("a"), 300)
+
+	payload := new(bytes.Buffer)
+	if err := ConstructPayload(payload, BinRpcTypeBytes, large); err != nil {
+		t.Fatalf("ConstructPayload: unexpected error: %s", err)
+	}
+
+	header := new(bytes.Buffer)
+	if err := ConstructHeader(header, uint64(payload.Len()), 0x1); err != nil {
+		t.Fatalf("ConstructHeader: unexpected error: %s", err)
+	}
+
+	full := append(header.Bytes(), payload.Bytes()...)
+	resp, err := Decode(bytes.NewReader(full))
+	if err != nil {
+		t.Fatalf("Decode: unexpected error: %s", err)
+	}
+	if len(resp.Values) != 1 {
+		t.Fatalf("expected 1 value, got %d", len(resp.Values))
+	}
+
+	got, err := resp.Values[0].AsBytes()
+	if err != nil {
+		t.Fatalf("AsBytes: unexpected error: %s", err)
+	}
+	if !bytes.Equal(got, large) {
+		t.Errorf("round-tripped bytes do not match: got %d bytes, want %d", len(got), len(large))
+	}
+}
+
+func TestEncodeRequestStructLargeMemberRoundTrip(t *testing.T) {
+	large := strings.Repeat("x", 150)
+
+	buf := new(bytes.Buffer)
+	if err := EncodeRequest(buf, 0xBEEF, "htable.seti", map[string]interface{}{"value": large}); err != nil {
+		t.Fatalf("EncodeRequest: unexpected error: %s", err)
+	}
+
+	resp, err := Decode(buf)
+	if err != nil {
+		t.Fatalf("Decode: unexpected error: %s", err)
+	}
+	if len(resp.Values) != 2 {
+		t.Fatalf("expected method name + 1 argument, got %d values", len(resp.Values))
+	}
+
+	members, err := resp.Values[1].AsStruct()
+	if err != nil {
+		t.Fatalf("AsStruct: unexpected error: %s", err)
+	}
+	got, err := members["value"].AsString()
+	if err != nil {
+		t.Fatalf("AsString: unexpected error: %s", err)
+	}
+	if got != large {
+		t.Errorf("struct member round-trip mismatch: got %d chars, want %d", len(got), len(large))
+	}
+}
+
+func TestEncodeRequestStructArgRoundTrip(t *testing.T) {
+	type setArgs struct {
+		Table string `binrpc:"table"`
+		Key   string `binrpc:"key"`
+		Value int32  `binrpc:"value"`
+	}
+
+	buf := new(bytes.Buffer)
+	arg := setArgs{Table: "ipban", Key: "10.0.0.1", Value: 1}
+	if err := EncodeRequest(buf, 0xC0FFEE, "htable.seti", arg); err != nil {
+		t.Fatalf("EncodeRequest: unexpected error: %s", err)
+	}
+
+	resp, err := Decode(buf)
+	if err != nil {
+		t.Fatalf("Decode: unexpected error: %s", err)
+	}
+	if len(resp.Values) != 2 {
+		t.Fatalf("expected method name + 1 argument, got %d values", len(resp.Values))
+	}
+
+	var got setArgs
+	if err := Unmarshal(resp.Values[1], &got); err != nil {
+		t.Fatalf("Unmarshal: unexpected error: %s", err)
+	}
+	if got != arg {
+		t.Errorf("struct arg round-trip mismatch: got %+v, want %+v", got, arg)
+	}
+}
+
 func TestRouting(t *testing.T) {
 }
 
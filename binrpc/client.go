@@ -0,0 +1,246 @@
+package binrpc
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+)
+
+// MaxPayloadLength is the largest payload a Client will accept from the
+// remote peer. Replies advertising a larger payloadLength are rejected
+// rather than read, to guard against a malicious or misbehaving peer
+// exhausting memory.
+const MaxPayloadLength = 16 * 1024 * 1024 // 16MiB
+
+// Client is a persistent, connection-oriented binrpc client. Unlike
+// InvokeMethod (which opens a new UDP socket per call and cannot
+// survive a reply larger than an MTU), Client keeps a single stream
+// open and multiplexes concurrent calls over it by cookie, in the
+// style of a ttrpc/gRPC framed connection.
+type Client struct {
+	conn net.Conn
+
+	mu       sync.Mutex
+	pending  map[uint32]chan callResult
+	closed   chan struct{}
+	closeErr error
+
+	// compressor, when non-nil, compresses outgoing payloads of at
+	// least compressThreshold bytes and tags them with
+	// compressFlag, so the peer knows how to decompress them.
+	// registry is consulted by readLoop to decompress replies, so it
+	// must be the same registry compressFlag was resolved from.
+	compressor        Compressor
+	compressFlag      uint
+	compressThreshold int
+	registry          *CompressorRegistry
+}
+
+type callResult struct {
+	resp *Response
+	err  error
+}
+
+// Dial opens a persistent binrpc connection to addr using the given
+// network ("tcp", "tcp4", "tcp6") and starts the background reader
+// that demultiplexes replies by cookie.
+func Dial(network, addr string) (*Client, error) {
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to kamailio RPC server: %w", err)
+	}
+
+	c := &Client{
+		conn:    conn,
+		pending: make(map[uint32]chan callResult),
+		closed:  make(chan struct{}),
+	}
+	go c.readLoop()
+
+	return c, nil
+}
+
+// Close terminates the underlying connection and fails any
+// in-flight calls.
+func (c *Client) Close() error {
+	err := c.conn.Close()
+	c.mu.Lock()
+	select {
+	case <-c.closed:
+	default:
+		close(c.closed)
+	}
+	c.mu.Unlock()
+	return err
+}
+
+// Call invokes method on the remote Kamailio server with the given
+// arguments and waits for the matching reply, honoring ctx
+// cancellation and deadlines.
+func (c *Client) Call(ctx context.Context, method string, args ...interface{}) (*Response, error) {
+	cookie := uint32(rand.Int63())
+
+	ch := make(chan callResult, 1)
+	c.mu.Lock()
+	if c.pending == nil {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("client is closed")
+	}
+	c.pending[cookie] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, cookie)
+		c.mu.Unlock()
+	}()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := c.conn.SetWriteDeadline(deadline); err != nil {
+			return nil, fmt.Errorf("failed to set write deadline: %w", err)
+		}
+	}
+
+	payload, err := encodeRequestPayload(method, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode RPC request: %w", err)
+	}
+
+	flags := FlagNone
+	c.mu.Lock()
+	compressor, threshold, flag := c.compressor, c.compressThreshold, c.compressFlag
+	c.mu.Unlock()
+	if compressor != nil && len(payload) >= threshold {
+		compressed, err := compressor.Compress(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress RPC request: %w", err)
+		}
+		payload = compressed
+		flags = flag
+	}
+
+	if err := writeFramedPayload(c.conn, cookie, flags, payload); err != nil {
+		return nil, fmt.Errorf("failed to write RPC request: %w", err)
+	}
+
+	select {
+	case res := <-ch:
+		return res.resp, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.closed:
+		return nil, fmt.Errorf("client closed while waiting for reply: %w", c.closeErr)
+	}
+}
+
+// UseCompression enables payload compression for outgoing calls,
+// compressing payloads of at least thresholdBytes with the compressor
+// registered under name in registry (DefaultCompressors if nil).
+// Prefer NegotiateCompression, which only enables compression once
+// the peer has confirmed it can decompress the chosen scheme.
+func (c *Client) UseCompression(name string, thresholdBytes int, registry *CompressorRegistry) error {
+	if registry == nil {
+		registry = DefaultCompressors
+	}
+	flag, ok := registry.Flag(name)
+	if !ok {
+		return fmt.Errorf("no compressor registered under name %q", name)
+	}
+	compressor, ok := registry.Get(flag)
+	if !ok {
+		return fmt.Errorf("no compressor registered for flag %x", flag)
+	}
+
+	c.mu.Lock()
+	c.compressor = compressor
+	c.compressFlag = flag
+	c.compressThreshold = thresholdBytes
+	c.registry = registry
+	c.mu.Unlock()
+	return nil
+}
+
+// NegotiateCompression calls capabilityMethod and enables compression
+// only if the peer's reply is a Struct whose "compression" member is
+// an Array listing name among the schemes it supports.
+func (c *Client) NegotiateCompression(ctx context.Context, capabilityMethod, name string, thresholdBytes int) error {
+	resp, err := c.Call(ctx, capabilityMethod)
+	if err != nil {
+		return fmt.Errorf("failed to query peer capabilities: %w", err)
+	}
+	if len(resp.Values) == 0 {
+		return fmt.Errorf("peer returned no capabilities")
+	}
+
+	caps, err := resp.Values[0].AsStruct()
+	if err != nil {
+		return fmt.Errorf("capabilities reply is not a Struct: %w", err)
+	}
+
+	compression, ok := caps["compression"]
+	if !ok {
+		return fmt.Errorf("peer did not advertise compression support")
+	}
+	supported, err := compression.AsArray()
+	if err != nil {
+		return fmt.Errorf("compression capability is not an Array: %w", err)
+	}
+
+	for _, v := range supported {
+		if s, err := v.AsString(); err == nil && s == name {
+			return c.UseCompression(name, thresholdBytes, nil)
+		}
+	}
+	return fmt.Errorf("peer does not support %q compression", name)
+}
+
+// readLoop reads replies off the connection for as long as it is
+// open, dispatching each to the channel registered for its cookie.
+func (c *Client) readLoop() {
+	r := bufio.NewReader(c.conn)
+	for {
+		c.mu.Lock()
+		registry := c.registry
+		c.mu.Unlock()
+
+		resp, err := decode(r, MaxPayloadLength, registry)
+		if err != nil {
+			c.failAll(err)
+			return
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[resp.Cookie]
+		c.mu.Unlock()
+		if !ok {
+			// No caller is waiting for this cookie (e.g. it
+			// already timed out); drop the reply.
+			continue
+		}
+		ch <- callResult{resp: resp}
+	}
+}
+
+// failAll delivers err to every pending call and marks the client
+// closed, e.g. after the connection is lost.
+func (c *Client) failAll(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closeErr = err
+	for cookie, ch := range c.pending {
+		ch <- callResult{err: err}
+		delete(c.pending, cookie)
+	}
+	select {
+	case <-c.closed:
+	default:
+		close(c.closed)
+	}
+}
+
+var _ io.Closer = (*Client)(nil)
@@ -0,0 +1,78 @@
+package binrpc
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+// writeTestReply writes a single-string-value binrpc reply under
+// cookie to w, mimicking what a Kamailio server would send back.
+func writeTestReply(w *bytes.Buffer, cookie uint32, s string) error {
+	payload := new(bytes.Buffer)
+	if err := ConstructPayload(payload, BinRpcTypeString, append([]byte(s), 0x0)); err != nil {
+		return err
+	}
+	header := new(bytes.Buffer)
+	if err := ConstructHeader(header, uint64(payload.Len()), cookie); err != nil {
+		return err
+	}
+	if _, err := header.WriteTo(w); err != nil {
+		return err
+	}
+	_, err := payload.WriteTo(w)
+	return err
+}
+
+func TestClientReadLoopMultiplexesByCookie(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close() // nolint
+	defer serverConn.Close() // nolint
+
+	c := &Client{
+		conn:    clientConn,
+		pending: make(map[uint32]chan callResult),
+		closed:  make(chan struct{}),
+	}
+	go c.readLoop()
+
+	ch1 := make(chan callResult, 1)
+	ch2 := make(chan callResult, 1)
+	c.mu.Lock()
+	c.pending[0x1] = ch1
+	c.pending[0x2] = ch2
+	c.mu.Unlock()
+
+	go func() {
+		// Reply to cookie 0x2 before 0x1, to prove readLoop
+		// dispatches by cookie rather than by arrival order.
+		buf := new(bytes.Buffer)
+		_ = writeTestReply(buf, 0x2, "second")
+		_ = writeTestReply(buf, 0x1, "first")
+		_, _ = serverConn.Write(buf.Bytes())
+	}()
+
+	res1 := <-ch1
+	if res1.err != nil {
+		t.Fatalf("call for cookie 0x1: unexpected error: %s", res1.err)
+	}
+	got1, err := res1.resp.Values[0].AsString()
+	if err != nil {
+		t.Fatalf("AsString: unexpected error: %s", err)
+	}
+	if got1 != "first" {
+		t.Errorf("cookie 0x1 got reply %q, want %q", got1, "first")
+	}
+
+	res2 := <-ch2
+	if res2.err != nil {
+		t.Fatalf("call for cookie 0x2: unexpected error: %s", res2.err)
+	}
+	got2, err := res2.resp.Values[0].AsString()
+	if err != nil {
+		t.Fatalf("AsString: unexpected error: %s", err)
+	}
+	if got2 != "second" {
+		t.Errorf("cookie 0x2 got reply %q, want %q", got2, "second")
+	}
+}
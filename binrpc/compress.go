@@ -0,0 +1,108 @@
+package binrpc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+)
+
+// Compression flag bits carried in the header's 4-bit Flags nibble.
+// FlagNone means the payload is sent raw; any other value identifies
+// the Compressor (via CompressorRegistry) used to compress it.
+const (
+	FlagNone uint = 0x0
+	FlagZlib uint = 0x1
+	FlagLZ4  uint = 0x2
+)
+
+// MaxDecompressedSize bounds how large a single payload may grow once
+// decompressed, so a small, wire-size-legal compressed message can't
+// expand into an allocation that exhausts memory.
+const MaxDecompressedSize = 64 * 1024 * 1024 // 64MiB
+
+// Compressor compresses and decompresses binrpc payloads for a single
+// compression scheme.
+type Compressor interface {
+	// Compress returns b compressed.
+	Compress(b []byte) ([]byte, error)
+	// Decompress returns b decompressed.
+	Decompress(b []byte) ([]byte, error)
+}
+
+// CompressorRegistry maps header flag bits to Compressor
+// implementations, so callers can plug in alternative codecs (e.g.
+// lz4) without modifying the core encode/decode path.
+type CompressorRegistry struct {
+	byFlag map[uint]Compressor
+	byName map[string]uint
+}
+
+// NewCompressorRegistry returns a registry pre-populated with the
+// built-in zlib compressor under FlagZlib.
+func NewCompressorRegistry() *CompressorRegistry {
+	r := &CompressorRegistry{
+		byFlag: make(map[uint]Compressor),
+		byName: make(map[string]uint),
+	}
+	r.Register(FlagZlib, "zlib", zlibCompressor{})
+	return r
+}
+
+// Register associates flag and name with c, so it can be looked up by
+// either when encoding or decoding a request.
+func (r *CompressorRegistry) Register(flag uint, name string, c Compressor) {
+	r.byFlag[flag] = c
+	r.byName[name] = flag
+}
+
+// Get returns the Compressor registered for flag, if any.
+func (r *CompressorRegistry) Get(flag uint) (Compressor, bool) {
+	c, ok := r.byFlag[flag]
+	return c, ok
+}
+
+// Flag returns the flag bits registered for the named compressor, if
+// any.
+func (r *CompressorRegistry) Flag(name string) (uint, bool) {
+	f, ok := r.byName[name]
+	return f, ok
+}
+
+// DefaultCompressors is the registry consulted by Decode and by
+// Client when no caller-specific registry has been configured.
+var DefaultCompressors = NewCompressorRegistry()
+
+type zlibCompressor struct{}
+
+func (zlibCompressor) Compress(b []byte) ([]byte, error) {
+	out := new(bytes.Buffer)
+	zw := zlib.NewWriter(out)
+	if _, err := zw.Write(b); err != nil {
+		return nil, fmt.Errorf("failed to write zlib payload: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close zlib writer: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+func (zlibCompressor) Decompress(b []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zlib reader: %w", err)
+	}
+	defer zr.Close() // nolint
+
+	// Cap how much we'll inflate a single payload to, so a small
+	// compressed message can't expand into an unbounded allocation
+	// (a "zip bomb").
+	out, err := io.ReadAll(io.LimitReader(zr, MaxDecompressedSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zlib payload: %w", err)
+	}
+	if len(out) > MaxDecompressedSize {
+		return nil, fmt.Errorf("decompressed payload exceeds maximum size of %d bytes", MaxDecompressedSize)
+	}
+	return out, nil
+}
@@ -0,0 +1,84 @@
+package binrpc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestZlibCompressorRoundTrip(t *testing.T) {
+	var z zlibCompressor
+	want := strings.Repeat("kamailio binrpc compression test ", 64)
+
+	compressed, err := z.Compress([]byte(want))
+	if err != nil {
+		t.Fatalf("Compress: unexpected error: %s", err)
+	}
+	if len(compressed) >= len(want) {
+		t.Errorf("compressed payload (%d bytes) is not smaller than input (%d bytes)", len(compressed), len(want))
+	}
+
+	got, err := z.Decompress(compressed)
+	if err != nil {
+		t.Fatalf("Decompress: unexpected error: %s", err)
+	}
+	if string(got) != want {
+		t.Errorf("round-tripped payload does not match: got %q, want %q", got, want)
+	}
+}
+
+func TestZlibCompressorDecompressRejectsOversizedOutput(t *testing.T) {
+	var z zlibCompressor
+	huge := bytes.Repeat([]byte("a"), MaxDecompressedSize+1)
+
+	compressed, err := z.Compress(huge)
+	if err != nil {
+		t.Fatalf("Compress: unexpected error: %s", err)
+	}
+
+	if _, err := z.Decompress(compressed); err == nil {
+		t.Error("Decompress: expected an error for output exceeding MaxDecompressedSize, got nil")
+	}
+}
+
+func TestDecodeUsesRegistryPassedToDecode(t *testing.T) {
+	payload := []byte("hello, kamailio")
+
+	valueBuf := new(bytes.Buffer)
+	if err := ConstructPayload(valueBuf, BinRpcTypeBytes, payload); err != nil {
+		t.Fatalf("ConstructPayload: unexpected error: %s", err)
+	}
+
+	var z zlibCompressor
+	compressed, err := z.Compress(valueBuf.Bytes())
+	if err != nil {
+		t.Fatalf("Compress: unexpected error: %s", err)
+	}
+
+	header := new(bytes.Buffer)
+	if err := ConstructHeaderFlags(header, uint64(len(compressed)), 0x1, FlagZlib); err != nil {
+		t.Fatalf("ConstructHeaderFlags: unexpected error: %s", err)
+	}
+
+	full := append(header.Bytes(), compressed...)
+
+	// A registry that doesn't know FlagZlib must fail, proving decode
+	// honors the registry it was given rather than silently falling
+	// back to DefaultCompressors.
+	empty := &CompressorRegistry{byFlag: map[uint]Compressor{}, byName: map[string]uint{}}
+	if _, err := decode(bytes.NewReader(full), 0, empty); err == nil {
+		t.Error("decode with a registry missing FlagZlib: expected an error, got nil")
+	}
+
+	resp, err := decode(bytes.NewReader(full), 0, DefaultCompressors)
+	if err != nil {
+		t.Fatalf("decode with DefaultCompressors: unexpected error: %s", err)
+	}
+	got, err := resp.Values[0].AsBytes()
+	if err != nil {
+		t.Fatalf("AsBytes: unexpected error: %s", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Errorf("decompressed payload mismatch: got %q, want %q", got, payload)
+	}
+}
@@ -0,0 +1,445 @@
+package binrpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// Response is a fully-decoded binrpc reply: the header fields plus the
+// top-level values that make up the payload.  Most Kamailio RPCs return
+// a single top-level value (often a Struct or Array), but the wire
+// format allows several.
+type Response struct {
+	Flags  uint
+	Cookie uint32
+	Values []*Value
+}
+
+// Value is a single decoded binrpc record.  Only the field matching
+// Type is meaningful; use the As* helpers rather than reading the
+// fields directly.
+type Value struct {
+	Type uint
+
+	// Name holds the member name when this Value was decoded as a
+	// child of a Struct, or the AVP name when it was decoded from an
+	// AVP record.  It is empty for any other value.
+	Name string
+
+	intVal   int64
+	strVal   string
+	dblVal   float64
+	bytesVal []byte
+	children []*Value
+}
+
+// AsInt returns the value as an int64. It returns an error if the
+// underlying record is not BinRpcTypeInt.
+func (v *Value) AsInt() (int64, error) {
+	if v.Type != BinRpcTypeInt {
+		return 0, fmt.Errorf("value is not an Int (type %d)", v.Type)
+	}
+	return v.intVal, nil
+}
+
+// AsString returns the value as a string. It returns an error if the
+// underlying record is not BinRpcTypeString.
+func (v *Value) AsString() (string, error) {
+	if v.Type != BinRpcTypeString {
+		return "", fmt.Errorf("value is not a String (type %d)", v.Type)
+	}
+	return v.strVal, nil
+}
+
+// AsDouble returns the value as a float64. It returns an error if the
+// underlying record is not BinRpcTypeDouble.
+func (v *Value) AsDouble() (float64, error) {
+	if v.Type != BinRpcTypeDouble {
+		return 0, fmt.Errorf("value is not a Double (type %d)", v.Type)
+	}
+	return v.dblVal, nil
+}
+
+// AsBytes returns the value as a raw byte slice. It returns an error if
+// the underlying record is not BinRpcTypeBytes.
+func (v *Value) AsBytes() ([]byte, error) {
+	if v.Type != BinRpcTypeBytes {
+		return nil, fmt.Errorf("value is not a Bytes (type %d)", v.Type)
+	}
+	return v.bytesVal, nil
+}
+
+// AsArray returns the children of an Array value in order.
+func (v *Value) AsArray() ([]*Value, error) {
+	if v.Type != BinRpcTypeArray {
+		return nil, fmt.Errorf("value is not an Array (type %d)", v.Type)
+	}
+	return v.children, nil
+}
+
+// AsStruct returns the children of a Struct value keyed by member name.
+func (v *Value) AsStruct() (map[string]*Value, error) {
+	if v.Type != BinRpcTypeStruct {
+		return nil, fmt.Errorf("value is not a Struct (type %d)", v.Type)
+	}
+	out := make(map[string]*Value, len(v.children))
+	for _, c := range v.children {
+		out[c.Name] = c
+	}
+	return out, nil
+}
+
+// Decode reads one binrpc reply (header + payload) from r and returns
+// the decoded Response, decompressing it against DefaultCompressors if
+// the peer flagged it as compressed. It rejects a header advertising a
+// payloadLength larger than MaxPayloadLength, guarding callers (e.g.
+// InvokeMethod) against a malicious or misbehaving peer exhausting
+// memory; use DecodeMax to allow a different bound. Unless r is
+// already a *bufio.Reader, Decode reads r through a new bufio.Reader
+// and may consume bytes beyond the end of the reply.
+func Decode(r io.Reader) (*Response, error) {
+	return decode(r, MaxPayloadLength, DefaultCompressors)
+}
+
+// DecodeMax is like Decode but rejects a header advertising a
+// payloadLength larger than maxPayload instead of the default
+// MaxPayloadLength. A maxPayload of 0 disables the check entirely.
+func DecodeMax(r io.Reader, maxPayload uint64) (*Response, error) {
+	return decode(r, maxPayload, DefaultCompressors)
+}
+
+// decode is the shared implementation behind Decode. maxPayload, when
+// non-zero, rejects a header advertising a payloadLength larger than
+// maxPayload before the payload is read, guarding against a malicious
+// or misbehaving peer. registry resolves the compressor named by the
+// header's Flags nibble, if any.
+func decode(r io.Reader, maxPayload uint64, registry *CompressorRegistry) (*Response, error) {
+	br := bufio.NewReader(r)
+
+	magicVersion, err := br.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read magic/version byte: %w", err)
+	}
+	magic := uint(magicVersion) >> 4
+	version := uint(magicVersion) & 0xF
+	if magic != BinRpcMagic {
+		return nil, fmt.Errorf("invalid binrpc magic: got %x, want %x", magic, BinRpcMagic)
+	}
+	if version != BinRpcVersion {
+		return nil, fmt.Errorf("invalid binrpc version: got %x, want %x", version, BinRpcVersion)
+	}
+
+	flb, err := br.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read flags/LL/CL byte: %w", err)
+	}
+	flags := uint(flb) >> 4
+	llSize := (uint(flb)>>2)&0x3 + 1
+	clSize := uint(flb)&0x3 + 1
+
+	payloadLength, err := readUint(br, llSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payload length: %w", err)
+	}
+
+	cookie, err := readUint(br, clSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cookie: %w", err)
+	}
+
+	if maxPayload > 0 && payloadLength > maxPayload {
+		return nil, fmt.Errorf("payload length %d exceeds maximum of %d", payloadLength, maxPayload)
+	}
+
+	raw := make([]byte, payloadLength)
+	if payloadLength > 0 {
+		if _, err := io.ReadFull(br, raw); err != nil {
+			return nil, fmt.Errorf("failed to read payload: %w", err)
+		}
+	}
+
+	if flags != FlagNone {
+		if registry == nil {
+			registry = DefaultCompressors
+		}
+		c, ok := registry.Get(flags)
+		if !ok {
+			return nil, fmt.Errorf("no compressor registered for flags %x", flags)
+		}
+		decompressed, err := c.Decompress(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress payload: %w", err)
+		}
+		raw = decompressed
+	}
+
+	payload := bytes.NewReader(raw)
+	var values []*Value
+	for {
+		v, err := decodeValue(payload, "")
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode payload: %w", err)
+		}
+		values = append(values, v)
+	}
+
+	return &Response{
+		Flags:  flags,
+		Cookie: uint32(cookie),
+		Values: values,
+	}, nil
+}
+
+// readUint reads n big-endian bytes from r and returns them as a uint64.
+func readUint(r io.Reader, n uint) (uint64, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return 0, err
+	}
+	var out uint64
+	for _, b := range buf {
+		out = out<<8 | uint64(b)
+	}
+	return out, nil
+}
+
+// decodeValue reads a single record (header + value) from r. name is
+// attached to the returned Value when decoding a Struct member. It
+// returns io.EOF when r is exhausted with no further record to read,
+// and also when the record read is an end-of-container marker.
+func decodeValue(r io.Reader, name string) (*Value, error) {
+	hb := make([]byte, 1)
+	if _, err := io.ReadFull(r, hb); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return nil, err
+	}
+
+	sflag := hb[0] >> 7
+	size := uint((hb[0] >> 4) & 0x7)
+	valType := uint(hb[0]) & 0xF
+
+	if valType == BinRpcTypeAll && size == 0 {
+		// end-of-container marker
+		return nil, io.EOF
+	}
+
+	var valueLength uint64
+	if sflag == 1 {
+		l, err := readUint(r, size)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read optional value length: %w", err)
+		}
+		valueLength = l
+	} else {
+		valueLength = uint64(size)
+	}
+
+	raw := make([]byte, valueLength)
+	if valueLength > 0 {
+		if _, err := io.ReadFull(r, raw); err != nil {
+			return nil, fmt.Errorf("failed to read value of length %d: %w", valueLength, err)
+		}
+	}
+
+	v := &Value{Type: valType, Name: name}
+
+	switch valType {
+	case BinRpcTypeInt:
+		var n int64
+		for _, b := range raw {
+			n = n<<8 | int64(b)
+		}
+		v.intVal = n
+	case BinRpcTypeString:
+		v.strVal = strings.TrimRight(string(raw), "\x00")
+	case BinRpcTypeDouble:
+		if len(raw) != 8 {
+			return nil, fmt.Errorf("invalid Double length: got %d, want 8", len(raw))
+		}
+		v.dblVal = math.Float64frombits(binary.BigEndian.Uint64(raw))
+	case BinRpcTypeBytes:
+		v.bytesVal = raw
+	case BinRpcTypeArray:
+		sub := bufio.NewReader(newByteReader(raw))
+		for {
+			child, err := decodeValue(sub, "")
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode array member: %w", err)
+			}
+			v.children = append(v.children, child)
+		}
+	case BinRpcTypeStruct:
+		sub := bufio.NewReader(newByteReader(raw))
+		for {
+			peeked, err := sub.Peek(1)
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				return nil, fmt.Errorf("failed to decode struct member name: %w", err)
+			}
+			if peeked[0] == endOfContainer {
+				_, _ = sub.Discard(1)
+				break
+			}
+			memberName, err := readCString(sub)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode struct member name: %w", err)
+			}
+			child, err := decodeValue(sub, memberName)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode struct member %q: %w", memberName, err)
+			}
+			v.children = append(v.children, child)
+		}
+	case BinRpcTypeAVP:
+		sub := bufio.NewReader(newByteReader(raw))
+		avpName, err := readCString(sub)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode AVP name: %w", err)
+		}
+		child, err := decodeValue(sub, avpName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode AVP value: %w", err)
+		}
+		child.Name = avpName
+		return child, nil
+	default:
+		return nil, fmt.Errorf("unknown binrpc type %d", valType)
+	}
+
+	return v, nil
+}
+
+// readCString reads a NUL-terminated string from r. It returns io.EOF
+// if r is exhausted before any bytes are read.
+func readCString(r io.ByteReader) (string, error) {
+	var b strings.Builder
+	read := false
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF && read {
+				return b.String(), nil
+			}
+			return "", err
+		}
+		read = true
+		if c == 0x0 {
+			return b.String(), nil
+		}
+		b.WriteByte(c)
+	}
+}
+
+func newByteReader(b []byte) io.Reader {
+	return &sliceReader{b: b}
+}
+
+// sliceReader is a minimal io.Reader over a byte slice, used to decode
+// container payloads that have already been read into memory.
+type sliceReader struct {
+	b   []byte
+	pos int
+}
+
+func (s *sliceReader) Read(p []byte) (int, error) {
+	if s.pos >= len(s.b) {
+		return 0, io.EOF
+	}
+	n := copy(p, s.b[s.pos:])
+	s.pos += n
+	return n, nil
+}
+
+// Unmarshal decodes v into the Go value pointed to by target. target
+// must be a non-nil pointer. Struct fields are matched against Struct
+// member names using a `binrpc:"name"` tag, falling back to the field
+// name.
+func Unmarshal(v *Value, target interface{}) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer")
+	}
+	return unmarshalValue(v, rv.Elem())
+}
+
+func unmarshalValue(v *Value, rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := v.AsInt()
+		if err != nil {
+			return err
+		}
+		rv.SetInt(n)
+	case reflect.String:
+		s, err := v.AsString()
+		if err != nil {
+			return err
+		}
+		rv.SetString(s)
+	case reflect.Float32, reflect.Float64:
+		d, err := v.AsDouble()
+		if err != nil {
+			return err
+		}
+		rv.SetFloat(d)
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b, err := v.AsBytes()
+			if err != nil {
+				return err
+			}
+			rv.SetBytes(b)
+			return nil
+		}
+		children, err := v.AsArray()
+		if err != nil {
+			return err
+		}
+		out := reflect.MakeSlice(rv.Type(), len(children), len(children))
+		for i, c := range children {
+			if err := unmarshalValue(c, out.Index(i)); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+		rv.Set(out)
+	case reflect.Struct:
+		members, err := v.AsStruct()
+		if err != nil {
+			return err
+		}
+		t := rv.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := field.Tag.Get("binrpc")
+			if name == "" {
+				name = field.Name
+			}
+			member, ok := members[name]
+			if !ok {
+				continue
+			}
+			if err := unmarshalValue(member, rv.Field(i)); err != nil {
+				return fmt.Errorf("field %q: %w", field.Name, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported target kind %s", rv.Kind())
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package binrpc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+)
+
+// endOfContainer is the raw byte for an end-marker record (sflag=0,
+// size=0, type=BinRpcTypeAll) that terminates a Struct or Array value.
+const endOfContainer = byte(0x0F)
+
+// EncodeRequest builds a single binrpc request message under cookie,
+// consisting of the method name followed by one record per argument,
+// and writes it to w.
+func EncodeRequest(w io.Writer, cookie uint32, method string, args ...interface{}) error {
+	payload, err := encodeRequestPayload(method, args...)
+	if err != nil {
+		return err
+	}
+	return writeFramedPayload(w, cookie, FlagNone, payload)
+}
+
+// encodeRequestPayload builds the raw, uncompressed payload bytes for
+// method and its arguments, without a header.
+func encodeRequestPayload(method string, args ...interface{}) ([]byte, error) {
+	payload := new(bytes.Buffer)
+
+	if err := ConstructPayload(payload, BinRpcTypeString, append([]byte(method), 0x0)); err != nil {
+		return nil, fmt.Errorf("failed to encode method name: %w", err)
+	}
+
+	for i, arg := range args {
+		if err := encodeArg(payload, arg); err != nil {
+			return nil, fmt.Errorf("failed to encode argument %d: %w", i, err)
+		}
+	}
+
+	return payload.Bytes(), nil
+}
+
+// writeFramedPayload wraps payload in a header under cookie and flags
+// and writes the whole message to w.
+func writeFramedPayload(w io.Writer, cookie uint32, flags uint, payload []byte) error {
+	header := new(bytes.Buffer)
+	if err := ConstructHeaderFlags(header, uint64(len(payload)), cookie, flags); err != nil {
+		return fmt.Errorf("failed to construct header: %w", err)
+	}
+
+	if _, err := header.WriteTo(w); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// encodeArg appends a single binrpc record for arg to buf, choosing
+// the record type from arg's Go type, mirroring the type mapping
+// Kamailio's own binrpc.c uses. Plain Go structs and typed slices are
+// normalized via reflection (see encodeReflectArg) into the map/slice
+// shapes handled directly below, so callers can pass ordinary args
+// structs the way the stdlib net/rpc idiom expects.
+func encodeArg(buf *bytes.Buffer, arg interface{}) error {
+	switch v := arg.(type) {
+	case int:
+		return ConstructPayload(buf, BinRpcTypeInt, int32(v))
+	case int32:
+		return ConstructPayload(buf, BinRpcTypeInt, v)
+	case string:
+		return ConstructPayload(buf, BinRpcTypeString, append([]byte(v), 0x0))
+	case float64:
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, math.Float64bits(v))
+		return ConstructPayload(buf, BinRpcTypeDouble, b)
+	case []byte:
+		return ConstructPayload(buf, BinRpcTypeBytes, v)
+	case map[string]interface{}:
+		return encodeStruct(buf, v)
+	case []interface{}:
+		return encodeArray(buf, v)
+	default:
+		return encodeReflectArg(buf, reflect.ValueOf(arg))
+	}
+}
+
+// encodeReflectArg handles argument types encodeArg doesn't match
+// directly: a plain Go struct is encoded as a Struct record, its
+// fields keyed by `binrpc:"name"` tag (falling back to the field
+// name) the same way Unmarshal reads them back; a typed slice/array
+// is encoded as an Array record, element by element.
+func encodeReflectArg(buf *bytes.Buffer, rv reflect.Value) error {
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return fmt.Errorf("unsupported argument type %s: nil pointer", rv.Type())
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		t := rv.Type()
+		m := make(map[string]interface{}, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" { // unexported
+				continue
+			}
+			name := field.Tag.Get("binrpc")
+			if name == "" {
+				name = field.Name
+			}
+			m[name] = rv.Field(i).Interface()
+		}
+		return encodeStruct(buf, m)
+	case reflect.Slice, reflect.Array:
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			items[i] = rv.Index(i).Interface()
+		}
+		return encodeArray(buf, items)
+	default:
+		return fmt.Errorf("unsupported argument type %s", rv.Type())
+	}
+}
+
+// encodeStruct encodes m as a Struct record: each member is a
+// NUL-terminated name followed by its value record, and the whole
+// member list is terminated by an end-of-container marker so the
+// receiver knows where the container ends.
+func encodeStruct(buf *bytes.Buffer, m map[string]interface{}) error {
+	inner := new(bytes.Buffer)
+	for name, val := range m {
+		inner.Write(append([]byte(name), 0x0))
+		if err := encodeArg(inner, val); err != nil {
+			return fmt.Errorf("struct member %q: %w", name, err)
+		}
+	}
+	inner.WriteByte(endOfContainer)
+	return ConstructPayload(buf, BinRpcTypeStruct, inner.Bytes())
+}
+
+// encodeArray encodes items as an Array record: each element is a
+// plain value record, terminated by an end-of-container marker.
+func encodeArray(buf *bytes.Buffer, items []interface{}) error {
+	inner := new(bytes.Buffer)
+	for i, val := range items {
+		if err := encodeArg(inner, val); err != nil {
+			return fmt.Errorf("array element %d: %w", i, err)
+		}
+	}
+	inner.WriteByte(endOfContainer)
+	return ConstructPayload(buf, BinRpcTypeArray, inner.Bytes())
+}
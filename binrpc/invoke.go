@@ -2,45 +2,40 @@ package binrpc
 
 import (
 	"fmt"
-	"io"
+	"math/rand"
 	"net"
 )
 
-type binRPCClientCodec struct {
-	c io.ReadWriteCloser
+// InvokeMethod calls the given RPC method on the given host and port,
+// decoding the reply into reply when non-nil.
+func InvokeMethod(method string, host string, port string, reply interface{}) error {
+	return InvokeMethodWithArgs(method, host, port, reply)
 }
 
-func (c *binRPCClientCodec) ReadResponseBody(body interface{}) error {
-	return nil
-}
-
-func (c *binRPCClientCodec) WriteRequest(name string) error {
-	var methodName = BinRpcString(name)
-	return methodName.Encode(c.c)
-}
-
-func newClientCodec(conn io.ReadWriteCloser) *binRPCClientCodec {
-	return &binRPCClientCodec{
-		c: conn,
-	}
-}
-
-// InvokeMethod calls the given RPC method on the given host and port
-func InvokeMethod(method string, host string, port string) error {
-
+// InvokeMethodWithArgs calls the given RPC method on the given host and
+// port with the given positional arguments, decoding the reply into
+// reply when non-nil. See EncodeRequest for the supported argument
+// types.
+func InvokeMethodWithArgs(method string, host string, port string, reply interface{}, args ...interface{}) error {
 	conn, err := net.Dial("udp", host+":"+port)
-	defer conn.Close() // nolint
-
 	if err != nil {
 		return fmt.Errorf("failed to connect to kamailio RPC server: %w", err)
 	}
+	defer conn.Close() // nolint
 
-	codec := newClientCodec(conn)
-	err = codec.WriteRequest(method)
+	cookie := uint32(rand.Int63())
+	if err := EncodeRequest(conn, cookie, method, args...); err != nil {
+		return fmt.Errorf("failed to invoke RPC method: %w", err)
+	}
 
+	resp, err := Decode(conn)
 	if err != nil {
-		return fmt.Errorf("failed to invoke RPC method: %w", err)
+		return fmt.Errorf("failed to read RPC response: %w", err)
+	}
+
+	if reply == nil || len(resp.Values) == 0 {
+		return nil
 	}
 
-	return nil
+	return Unmarshal(resp.Values[0], reply)
 }
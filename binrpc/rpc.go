@@ -0,0 +1,93 @@
+package binrpc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/rpc"
+)
+
+// binRPCClientCodec adapts a binrpc connection to the stdlib
+// net/rpc.ClientCodec interface, mapping rpc.Request.Seq onto the
+// 32-bit binrpc cookie so replies are correlated the same way any
+// other net/rpc transport correlates them.
+type binRPCClientCodec struct {
+	c io.ReadWriteCloser
+
+	// r buffers reads from c across replies, so bytes of a following
+	// reply read ahead while decoding one reply are not lost.
+	r *bufio.Reader
+
+	// pending holds the reply decoded by ReadResponseHeader until
+	// the following ReadResponseBody call consumes it.
+	pending *Response
+}
+
+func newClientCodec(conn io.ReadWriteCloser) *binRPCClientCodec {
+	return &binRPCClientCodec{c: conn, r: bufio.NewReader(conn)}
+}
+
+// NewClient returns a *rpc.Client that issues calls over conn using
+// the binrpc wire protocol, so callers can use the stdlib idiom:
+//
+//	client := binrpc.NewClient(conn)
+//	err := client.Call("dispatcher.list", args, &reply)
+func NewClient(conn io.ReadWriteCloser) *rpc.Client {
+	return rpc.NewClientWithCodec(newClientCodec(conn))
+}
+
+// WriteRequest encodes req.ServiceMethod and args as a single binrpc
+// request under req.Seq (truncated to 32 bits, binrpc's cookie width)
+// and writes it to the connection. args may be nil (no arguments), a
+// []interface{} of positional arguments, or a single value of one of
+// the types encodeArg supports.
+func (c *binRPCClientCodec) WriteRequest(req *rpc.Request, args interface{}) error {
+	cookie := uint32(req.Seq)
+
+	var argList []interface{}
+	switch v := args.(type) {
+	case nil:
+	case []interface{}:
+		argList = v
+	default:
+		argList = []interface{}{v}
+	}
+
+	return EncodeRequest(c.c, cookie, req.ServiceMethod, argList...)
+}
+
+// ReadResponseHeader decodes the next reply off the connection and
+// stashes it for the following ReadResponseBody call, reporting the
+// cookie back as resp.Seq so net/rpc can match it to the waiting
+// call.
+func (c *binRPCClientCodec) ReadResponseHeader(resp *rpc.Response) error {
+	decoded, err := Decode(c.r)
+	if err != nil {
+		return fmt.Errorf("failed to decode RPC response: %w", err)
+	}
+
+	resp.Seq = uint64(decoded.Cookie)
+	c.pending = decoded
+	return nil
+}
+
+// ReadResponseBody decodes the reply stashed by ReadResponseHeader
+// into body via reflection. A nil body discards the reply, which is
+// what net/rpc passes when the call already errored.
+func (c *binRPCClientCodec) ReadResponseBody(body interface{}) error {
+	decoded := c.pending
+	c.pending = nil
+
+	if body == nil || decoded == nil || len(decoded.Values) == 0 {
+		return nil
+	}
+
+	return Unmarshal(decoded.Values[0], body)
+}
+
+// Close closes the underlying connection.
+func (c *binRPCClientCodec) Close() error {
+	return c.c.Close()
+}
+
+var _ rpc.ClientCodec = (*binRPCClientCodec)(nil)